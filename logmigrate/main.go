@@ -0,0 +1,112 @@
+// Command logmigrate rewrites a transaction.log file from the legacy
+// tab-separated text format used by earlier versions of the transaction
+// logger into the current length-prefixed binary format with per-record
+// CRC32C checksums.
+//
+// Usage:
+//
+//	logmigrate -in transaction.log -out transaction.log.new
+package main
+
+import (
+	"bufio"
+	"encoding/binary"
+	"flag"
+	"fmt"
+	"hash/crc32"
+	"io"
+	"log"
+	"os"
+)
+
+type legacyEvent struct {
+	Sequence  uint64
+	EventType uint8
+	Key       string
+	Value     string
+}
+
+var crc32cTable = crc32.MakeTable(crc32.Castagnoli)
+
+func readLegacyEvent(line string) (legacyEvent, error) {
+	var e legacyEvent
+	_, err := fmt.Sscanf(line, "%d\t%d\t\t%s\t%s", &e.Sequence, &e.EventType, &e.Key, &e.Value)
+	return e, err
+}
+
+// writeBinaryEvent writes e to w in the new length-prefixed binary format:
+// [uint32 total_len][uint64 seq][uint8 type][uint16 key_len][key bytes]
+// [uint32 val_len][val bytes][uint32 crc32c].
+func writeBinaryEvent(w io.Writer, e legacyEvent) error {
+	key := []byte(e.Key)
+	val := []byte(e.Value)
+
+	body := make([]byte, 0, 8+1+2+len(key)+4+len(val))
+	body = binary.BigEndian.AppendUint64(body, e.Sequence)
+	body = append(body, e.EventType)
+	body = binary.BigEndian.AppendUint16(body, uint16(len(key)))
+	body = append(body, key...)
+	body = binary.BigEndian.AppendUint32(body, uint32(len(val)))
+	body = append(body, val...)
+
+	checksum := crc32.Checksum(body, crc32cTable)
+
+	record := make([]byte, 0, 4+len(body)+4)
+	record = binary.BigEndian.AppendUint32(record, uint32(len(body)))
+	record = append(record, body...)
+	record = binary.BigEndian.AppendUint32(record, checksum)
+
+	_, err := w.Write(record)
+	return err
+}
+
+func migrate(in io.Reader, out io.Writer) (int, error) {
+	scanner := bufio.NewScanner(in)
+	writer := bufio.NewWriter(out)
+
+	var count int
+	for scanner.Scan() {
+		e, err := readLegacyEvent(scanner.Text())
+		if err != nil {
+			return count, fmt.Errorf("line %d: %w", count+1, err)
+		}
+		if err := writeBinaryEvent(writer, e); err != nil {
+			return count, fmt.Errorf("line %d: %w", count+1, err)
+		}
+		count++
+	}
+	if err := scanner.Err(); err != nil {
+		return count, fmt.Errorf("reading legacy log: %w", err)
+	}
+
+	return count, writer.Flush()
+}
+
+func main() {
+	inPath := flag.String("in", "transaction.log", "path to the legacy text-format transaction log")
+	outPath := flag.String("out", "transaction.log.new", "path to write the migrated binary-format log to")
+	flag.Parse()
+
+	in, err := os.Open(*inPath)
+	if err != nil {
+		log.Fatalf("cannot open input log: %v", err)
+	}
+	defer in.Close()
+
+	out, err := os.OpenFile(*outPath, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, 0755)
+	if err != nil {
+		log.Fatalf("cannot create output log: %v", err)
+	}
+	defer out.Close()
+
+	count, err := migrate(in, out)
+	if err != nil {
+		log.Fatalf("migration failed after %d records: %v", count, err)
+	}
+
+	if err := out.Sync(); err != nil {
+		log.Fatalf("cannot sync output log: %v", err)
+	}
+
+	fmt.Printf("migrated %d records from %s to %s\n", count, *inPath, *outPath)
+}
@@ -0,0 +1,81 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+
+	"github.com/hashicorp/raft"
+)
+
+// fsm implements raft.FSM on top of the existing myMap machinery. Apply runs
+// once per committed log entry on every node in the cluster -- leader and
+// followers alike -- including entries Raft replays from its own log store
+// to rebuild myMap after a restart (small clusters replay their whole log
+// rather than restoring from a snapshot far more often than not, since
+// raft.DefaultConfig's SnapshotThreshold/SnapshotInterval are tuned for much
+// larger logs). Raft's log and snapshot store are this node's durability
+// mechanism in replicated mode, so Apply only ever touches myMap; it must
+// not also drive the local TransactionLogger, or every replay would
+// re-append every event to transaction.log/Postgres without bound. The
+// TransactionLogger stays wired up for the non-replicated path only -- see
+// initializeReplicator in main.go.
+type fsm struct{}
+
+func (f *fsm) Apply(log *raft.Log) interface{} {
+	var e Event
+	if err := json.Unmarshal(log.Data, &e); err != nil {
+		return fmt.Errorf("failed to unmarshal raft log entry: %w", err)
+	}
+
+	switch e.EventType {
+	case EventPut:
+		return Put(e.Key, e.Value)
+	case EventDelete:
+		return Delete(e.Key)
+	default:
+		return fmt.Errorf("unsupported event type in raft log: %d", e.EventType)
+	}
+}
+
+func (f *fsm) Snapshot() (raft.FSMSnapshot, error) {
+	myMap.RLock()
+	defer myMap.RUnlock()
+
+	data := make(map[string]string, len(myMap.m))
+	for k, v := range myMap.m {
+		data[k] = v
+	}
+	return &fsmSnapshot{data: data}, nil
+}
+
+func (f *fsm) Restore(rc io.ReadCloser) error {
+	defer rc.Close()
+
+	var data map[string]string
+	if err := json.NewDecoder(rc).Decode(&data); err != nil {
+		return fmt.Errorf("failed to decode raft snapshot: %w", err)
+	}
+
+	myMap.Lock()
+	myMap.m = data
+	myMap.Unlock()
+	return nil
+}
+
+// fsmSnapshot is the raft.FSMSnapshot companion to fsm.Snapshot. It holds a
+// point-in-time copy of myMap so Persist can run without holding myMap's
+// lock for the duration of the (possibly slow) write to sink.
+type fsmSnapshot struct {
+	data map[string]string
+}
+
+func (s *fsmSnapshot) Persist(sink raft.SnapshotSink) error {
+	if err := json.NewEncoder(sink).Encode(s.data); err != nil {
+		sink.Cancel()
+		return err
+	}
+	return sink.Close()
+}
+
+func (s *fsmSnapshot) Release() {}
@@ -0,0 +1,125 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/sirupsen/logrus"
+)
+
+// appLog is the structured logger shared by every package-level function
+// (Get, Put, Delete, initializeTransactionLog, fsm.Apply) that isn't itself
+// a method on Server. Server.log points at the same instance, so handlers
+// and background code end up in the same log stream.
+var appLog = logrus.New()
+
+func init() {
+	appLog.SetFormatter(&logrus.JSONFormatter{})
+}
+
+type requestLoggerKey struct{}
+
+// loggerFromContext returns the request-scoped logger attached by
+// correlationIDMiddleware, falling back to appLog for callers outside an
+// HTTP request (tests, replay during startup).
+func loggerFromContext(ctx context.Context) *logrus.Entry {
+	if entry, ok := ctx.Value(requestLoggerKey{}).(*logrus.Entry); ok {
+		return entry
+	}
+	return logrus.NewEntry(appLog)
+}
+
+// statusRecorder wraps http.ResponseWriter so the access log can report the
+// status code and byte count a handler actually wrote.
+type statusRecorder struct {
+	http.ResponseWriter
+	status int
+	bytes  int
+}
+
+func (rec *statusRecorder) WriteHeader(status int) {
+	rec.status = status
+	rec.ResponseWriter.WriteHeader(status)
+}
+
+func (rec *statusRecorder) Write(b []byte) (int, error) {
+	if rec.status == 0 {
+		rec.status = http.StatusOK
+	}
+	n, err := rec.ResponseWriter.Write(b)
+	rec.bytes += n
+	return n, err
+}
+
+// correlationIDMiddleware assigns every request a correlation ID (taken
+// from X-Request-ID when present, generated otherwise), attaches a child
+// logger carrying it to the request context, and emits one access-log
+// entry per request with method, key, status, duration and bytes written.
+func (s *Server) correlationIDMiddleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		correlationID := r.Header.Get("X-Request-ID")
+		if correlationID == "" {
+			correlationID = uuid.NewString()
+		}
+
+		entry := s.log.WithField("correlation_id", correlationID)
+		r = r.WithContext(context.WithValue(r.Context(), requestLoggerKey{}, entry))
+		w.Header().Set("X-Request-ID", correlationID)
+
+		rec := &statusRecorder{ResponseWriter: w}
+		start := time.Now()
+
+		next.ServeHTTP(rec, r)
+
+		duration := time.Since(start)
+		recordRequestMetrics(r.URL.Path, r.Method, rec.status, duration)
+
+		entry.WithFields(logrus.Fields{
+			"method":   r.Method,
+			"path":     r.URL.Path,
+			"status":   rec.status,
+			"duration": duration,
+			"bytes":    rec.bytes,
+		}).Info("handled request")
+	})
+}
+
+// TransactionError associates a transaction log write failure with the
+// event it happened on, so it can be logged with sequence number and event
+// type in scope instead of a bare error string.
+type TransactionError struct {
+	Sequence  uint64
+	EventType EventType
+	Err       error
+}
+
+func (e *TransactionError) Error() string {
+	return e.Err.Error()
+}
+
+func (e *TransactionError) Unwrap() error {
+	return e.Err
+}
+
+// watchTransactionLoggerErrors logs everything that comes out of tl.Err()
+// until the channel is closed.
+func (s *Server) watchTransactionLoggerErrors(tl TransactionLogger) {
+	go func() {
+		for err := range tl.Err() {
+			entry := s.log.WithError(err)
+
+			var txErr *TransactionError
+			if errors.As(err, &txErr) {
+				entry = entry.WithFields(logrus.Fields{
+					"sequence":   txErr.Sequence,
+					"event_type": txErr.EventType,
+				})
+			}
+
+			entry.Error("transaction logger reported an error")
+		}
+	}()
+}
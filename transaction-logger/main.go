@@ -5,12 +5,12 @@ import (
 	"errors"
 	"fmt"
 	"io"
-	"log"
-	"net/http"
 	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
 	"sync"
-
-	"github.com/gorilla/mux"
+	"sync/atomic"
 )
 
 type Event struct {
@@ -30,10 +30,14 @@ const (
 )
 
 type FileTransactionLogger struct {
-	events       chan<- Event // Write-only channel for sending events
-	errors       <-chan error // Read-only channel for receiving errors
-	lastSequence uint64       // The last used event sequence number
-	file         *os.File     // The location of the transaction log
+	events       chan<- Event  // Write-only channel for sending events
+	errors       <-chan error  // Read-only channel for receiving errors
+	lastSequence atomic.Uint64 // The last used event sequence number; read by the metrics collector, so it's atomic rather than a plain uint64
+	file         *os.File      // The location of the transaction log
+	filename     string        // Path to file, kept so snapshots land next to it
+
+	snapshotInterval    uint64 // Take a snapshot every this many events (0 disables)
+	eventsSinceSnapshot uint64 // Events written since the last snapshot
 }
 
 func (l *FileTransactionLogger) WritePut(key, value string) {
@@ -63,12 +67,38 @@ type TransactionLogger interface {
 	Run()
 }
 
+// LoggerKind identifies which TransactionLogger backend to construct.
+type LoggerKind string
+
+const (
+	LoggerKindFile     LoggerKind = "file"
+	LoggerKindPostgres LoggerKind = "postgres"
+)
+
+// NewTransactionLogger constructs a TransactionLogger of the given kind.
+// dsn is interpreted as a file path for LoggerKindFile and as a
+// database connection string for LoggerKindPostgres.
+func NewTransactionLogger(kind LoggerKind, dsn string) (TransactionLogger, error) {
+	switch kind {
+	case LoggerKindPostgres:
+		return NewPostgresTransactionLogger(PostgresDBParams{DSN: dsn})
+	case LoggerKindFile, "":
+		return NewFileTransactionLogger(dsn)
+	default:
+		return nil, fmt.Errorf("unsupported transaction logger kind: %q", kind)
+	}
+}
+
 func NewFileTransactionLogger(filename string) (TransactionLogger, error) {
 	file, err := os.OpenFile(filename, os.O_RDWR|os.O_APPEND|os.O_CREATE, 0755)
 	if err != nil {
 		return nil, fmt.Errorf("cannot open transaction log file: %w", err)
 	}
-	return &FileTransactionLogger{file: file}, nil
+	return &FileTransactionLogger{
+		file:             file,
+		filename:         filename,
+		snapshotInterval: defaultSnapshotInterval,
+	}, nil
 }
 
 func (l *FileTransactionLogger) Run() {
@@ -79,56 +109,84 @@ func (l *FileTransactionLogger) Run() {
 	l.errors = errors
 
 	go func() {
+		writer := bufio.NewWriter(l.file)
+
 		for e := range events { // Retrieve the next Event
+			seq := l.lastSequence.Add(1) // Increment sequence number
+			e.Sequence = seq
 
-			l.lastSequence++ // Increment sequence number
+			if err := writeEventBinary(writer, e); err != nil {
+				errors <- &TransactionError{Sequence: seq, EventType: e.EventType, Err: err}
+				return
+			}
 
-			_, err := fmt.Fprintf( // Write the event to the log
-				l.file,
-				"%d\t%d\t%s\t\t%s\n",
-				l.lastSequence, e.EventType, e.Key, e.Value)
+			// Flush and fsync at a batch boundary -- either the events
+			// channel has momentarily drained, or a snapshot is about to
+			// truncate the file and needs every write durable first.
+			atSnapshotBoundary := l.snapshotInterval > 0 && l.eventsSinceSnapshot+1 >= l.snapshotInterval
+			if len(events) == 0 || atSnapshotBoundary {
+				if err := writer.Flush(); err != nil {
+					errors <- &TransactionError{Sequence: seq, EventType: e.EventType, Err: err}
+					return
+				}
+				if err := l.file.Sync(); err != nil {
+					errors <- &TransactionError{Sequence: seq, EventType: e.EventType, Err: err}
+					return
+				}
+			}
 
-			if err != nil {
-				errors <- err
-				return
+			l.eventsSinceSnapshot++
+			if atSnapshotBoundary {
+				if err := l.takeSnapshot(seq); err != nil {
+					errors <- &TransactionError{Sequence: seq, EventType: e.EventType, Err: err}
+					return
+				}
 			}
 		}
 	}()
 }
 
+// PendingEvents reports how many events are buffered in the write channel
+// but not yet flushed to disk -- the replication lag a metrics scraper
+// cares about.
+func (l *FileTransactionLogger) PendingEvents() int {
+	return len(l.events)
+}
+
+// LastSequence reports the most recently flushed event's sequence number.
+func (l *FileTransactionLogger) LastSequence() uint64 {
+	return l.lastSequence.Load()
+}
+
 func (l *FileTransactionLogger) ReadEvents() (<-chan Event, <-chan error) {
-	scanner := bufio.NewScanner(l.file) // Create a Scanner for l.file
-	outEvent := make(chan Event)        // An unbuffered Event channel
-	outError := make(chan error, 1)     // A buffered error channel
+	reader := bufio.NewReader(l.file)
+	outEvent := make(chan Event)    // An unbuffered Event channel
+	outError := make(chan error, 1) // A buffered error channel
 
 	go func() {
-		var e Event
-
 		defer close(outEvent)
 		defer close(outError)
 
-		for scanner.Scan() {
-			line := scanner.Text()
-
-			if _, err := fmt.Sscanf(line, "%d\t%d\t\t%s\t%s", &e.Sequence, &e.EventType, &e.Key, &e.Value); err != nil {
-				outError <- fmt.Errorf("input parse error: %w", &err)
+		for {
+			e, err := readEventBinary(reader)
+			if err == io.EOF {
 				return
 			}
-
-			// Sanity check! Are the sequence numbers in increasing order
-			if l.lastSequence >= e.Sequence {
-				outError <- fmt.Errorf("transaction numbers out of sequence")
+			if err != nil {
+				outError <- fmt.Errorf("transaction log read failure: %w", err)
 				return
 			}
 
-			l.lastSequence = e.Sequence
+			// Events at or before l.lastSequence were already captured by a
+			// snapshot taken before this log segment started; skip them
+			// instead of replaying (and double-applying) them.
+			if e.Sequence <= l.lastSequence.Load() {
+				continue
+			}
 
-			outEvent <- e
-		}
+			l.lastSequence.Store(e.Sequence)
 
-		if err := scanner.Err(); err != nil {
-			outError <- fmt.Errorf("transaction log read failure: %w", err)
-			return
+			outEvent <- e
 		}
 	}()
 	return outEvent, outError
@@ -136,6 +194,11 @@ func (l *FileTransactionLogger) ReadEvents() (<-chan Event, <-chan error) {
 
 var logger TransactionLogger
 
+// replicator is non-nil when the server is running as part of a Raft
+// cluster. When nil, Put/Delete apply directly to myMap and the local
+// transaction log, exactly as they did before replication existed.
+var replicator *Replicator
+
 // Define the struct with embedded RWMutex and a map
 var myMap = struct {
 	sync.RWMutex
@@ -145,16 +208,16 @@ var myMap = struct {
 func Get(key string) (string, error) {
 	myMap.RLock()
 	defer myMap.RUnlock()
-	// Check if the key is present
+
 	value, exists := myMap.m[key]
-	fmt.Println("GET function: Attempting to get key:", key)
+	log := appLog.WithField("key", key)
 
 	if !exists {
-		fmt.Println("GET function: Key not found")
+		log.Debug("key not found")
 		return "", ErrorNoSuchKey
 	}
 
-	fmt.Println("GET function: Found value:", value)
+	log.Debug("key found")
 	return value, nil
 }
 
@@ -174,56 +237,34 @@ func Delete(key string) error {
 
 var ErrorNoSuchKey = errors.New("no such key")
 
-func keyValueGetHandler(w http.ResponseWriter, r *http.Request) {
-	vars := mux.Vars(r)
-	key := vars["key"] // // Retrieve "key" from the request key := vars["key"]
+func initializeTransactionLog() error {
 
-	value, err := Get(key)
-	logger.WriteGet(key, string(value))
+	var err error
 
-	if errors.Is(err, ErrorNoSuchKey) {
-		http.Error(w, err.Error(), http.StatusNotFound)
-		return
+	kind := LoggerKind(os.Getenv("TRANSACTION_LOG_KIND"))
+	dsn := os.Getenv("TRANSACTION_LOG_DSN")
+	if kind == "" || kind == LoggerKindFile {
+		if dsn == "" {
+			dsn = "transaction.log"
+		}
 	}
 
-	w.Write([]byte(value))
-}
-
-func keyValuePutHandler(w http.ResponseWriter, r *http.Request) {
-	vars := mux.Vars(r)
-	key := vars["key"]
-
-	value, err := io.ReadAll(r.Body)
-	defer r.Body.Close()
-	Put(key, string(value))
-
-	logger.WritePut(key, string(value))
-
+	logger, err = NewTransactionLogger(kind, dsn)
 	if err != nil {
-		http.Error(w,
-			err.Error(),
-			http.StatusInternalServerError)
+		return fmt.Errorf("failed to create event logger: %w", err)
 	}
 
-	w.WriteHeader(http.StatusCreated)
-
-}
-
-func keyValueDeleteHandler(w http.ResponseWriter, r *http.Request) {
-	vars := mux.Vars(r)
-	key := vars["key"] // Retrieve "key" from the request key := vars["key"]
-	Delete(key)
-	logger.WriteDelete(key)
-	w.WriteHeader((http.StatusResetContent))
-}
-
-func initializeTransactionLog() error {
-
-	var err error
-
-	logger, err = NewFileTransactionLogger("transaction.log")
-	if err != nil {
-		return fmt.Errorf("failed to create event logger: %w", err)
+	if fileLogger, ok := logger.(*FileTransactionLogger); ok {
+		snapshotSeq, snapshot, err := loadLatestSnapshot(filepath.Dir(fileLogger.filename))
+		if err != nil {
+			return fmt.Errorf("failed to load snapshot: %w", err)
+		}
+		if snapshot != nil {
+			myMap.Lock()
+			myMap.m = snapshot
+			myMap.Unlock()
+			fileLogger.lastSequence.Store(snapshotSeq)
+		}
 	}
 
 	events, errors := logger.ReadEvents()
@@ -251,16 +292,94 @@ func initializeTransactionLog() error {
 
 }
 
+// parsePeers parses a PEERS environment variable of the form
+// "raftAddr1=httpAddr1,raftAddr2=httpAddr2" into the map LeaderHTTPAddr
+// needs to translate a Raft leader address into one HTTP clients can dial.
+func parsePeers(peers string) (map[string]string, error) {
+	result := make(map[string]string)
+	if peers == "" {
+		return result, nil
+	}
+
+	for _, pair := range strings.Split(peers, ",") {
+		raftAddr, httpAddr, ok := strings.Cut(pair, "=")
+		if !ok {
+			return nil, fmt.Errorf("malformed PEERS entry %q; want raftAddr=httpAddr", pair)
+		}
+		result[raftAddr] = httpAddr
+	}
+	return result, nil
+}
+
+// replicationEnabled reports whether REPLICATION_ENABLED is set. It's the
+// single switch between the two durability modes: replicated (Raft's own
+// log and snapshot store, see fsm.go) and standalone (the local
+// TransactionLogger), so main and initializeReplicator both read it through
+// here rather than parsing the env var twice.
+func replicationEnabled() bool {
+	ok, _ := strconv.ParseBool(os.Getenv("REPLICATION_ENABLED"))
+	return ok
+}
+
+// initializeReplicator wires up Raft if replicationEnabled reports true,
+// leaving replicator nil (and the server running single-node, as before)
+// otherwise.
+func initializeReplicator(httpAddr string) error {
+	if !replicationEnabled() {
+		return nil
+	}
+
+	bootstrap, _ := strconv.ParseBool(os.Getenv("RAFT_BOOTSTRAP"))
+	raftBind := os.Getenv("RAFT_BIND")
+
+	peers, err := parsePeers(os.Getenv("PEERS"))
+	if err != nil {
+		return err
+	}
+	// This node's own mapping is required for LeaderHTTPAddr to work when
+	// this node is the leader; default it from RAFT_BIND/HTTP_ADDR so it
+	// doesn't have to be repeated in PEERS.
+	if _, ok := peers[raftBind]; !ok {
+		peers[raftBind] = httpAddr
+	}
+
+	replicator, err = NewReplicator(ReplicatorConfig{
+		NodeID:    os.Getenv("NODE_ID"),
+		RaftDir:   os.Getenv("RAFT_DIR"),
+		RaftBind:  raftBind,
+		Bootstrap: bootstrap,
+		Peers:     peers,
+	})
+	return err
+}
+
 func main() {
+	s := NewServer()
 
-	r := mux.NewRouter()
-	r.HandleFunc("/v1/{key}", keyValueGetHandler).Methods("GET")
-	r.HandleFunc("/v1/{key}", keyValuePutHandler).Methods("PUT")
-	r.HandleFunc("/v1/{key}", keyValueDeleteHandler).Methods("DELETE")
+	httpAddr := os.Getenv("HTTP_ADDR")
+	if httpAddr == "" {
+		httpAddr = ":8080"
+	}
 
-	initializeTransactionLog()
+	// In replicated mode, fsm.Apply relies on Raft's own log and snapshot
+	// store for durability and never touches the local TransactionLogger
+	// (see fsm.go), so there's nothing for it to replay here and logger
+	// stays nil. In standalone mode this is the only durability path, same
+	// as before replication existed.
+	if !replicationEnabled() {
+		if err := initializeTransactionLog(); err != nil {
+			s.log.WithError(err).Fatal("failed to initialize transaction log")
+		}
+		s.watchTransactionLoggerErrors(logger)
+	}
+
+	if err := initializeReplicator(httpAddr); err != nil {
+		s.log.WithError(err).Fatal("failed to initialize replicator")
+	}
 
-	fmt.Println("Listening . . .")
-	log.Fatal(http.ListenAndServe(":8080", r))
+	startMetricsCollector(logger)
+	ready.Store(true)
 
+	s.log.WithField("addr", httpAddr).Info("listening")
+	s.log.Fatal(s.ListenAndServe(httpAddr))
 }
@@ -0,0 +1,199 @@
+package main
+
+import (
+	"database/sql"
+	"fmt"
+	"time"
+
+	_ "github.com/lib/pq"
+)
+
+// PostgresDBParams holds the connection parameters for a PostgresTransactionLogger.
+type PostgresDBParams struct {
+	DSN string
+}
+
+// PostgresTransactionLogger is a TransactionLogger backed by a Postgres table
+// instead of a flat file. Every event is appended as a row and replayed on
+// startup via an ORDER BY sequence SELECT, giving the same replay semantics
+// as FileTransactionLogger but with the durability and scaling properties of
+// an external database.
+type PostgresTransactionLogger struct {
+	events       chan<- Event
+	errors       <-chan error
+	db           *sql.DB
+	lastSequence uint64
+}
+
+func (l *PostgresTransactionLogger) WritePut(key, value string) {
+	l.events <- Event{EventType: EventPut, Key: key, Value: value}
+}
+
+func (l *PostgresTransactionLogger) WriteDelete(key string) {
+	l.events <- Event{EventType: EventDelete, Key: key}
+}
+
+func (l *PostgresTransactionLogger) WriteGet(key, value string) {
+	l.events <- Event{EventType: EventGet, Key: key, Value: value}
+}
+
+func (l *PostgresTransactionLogger) Err() <-chan error {
+	return l.errors
+}
+
+// startupRetryAttempts bounds the retries around connecting and replaying
+// on startup. A coordinated restart routinely has the app container come
+// back before its database does, so this path needs the same resilience as
+// the write path, or restarts against Postgres don't restore state "as
+// identically to the file backend" as the rest of this logger promises.
+const startupRetryAttempts = 5
+
+// withRetry runs op, retrying with exponential backoff up to maxAttempts
+// times. It's shared by every place this logger talks to Postgres -
+// startup connect, replay, and the write loop - since all three can hit the
+// same transient connectivity blip.
+func withRetry(maxAttempts int, op func() error) error {
+	backoff := 100 * time.Millisecond
+
+	var err error
+	for attempt := 1; attempt <= maxAttempts; attempt++ {
+		if err = op(); err == nil {
+			return nil
+		}
+		if attempt < maxAttempts {
+			time.Sleep(backoff)
+			backoff *= 2
+		}
+	}
+	return err
+}
+
+// NewPostgresTransactionLogger opens a connection pool against dsn, verifies
+// it with a ping, and makes sure the transactions table exists. Both steps
+// retry with backoff, since a restart can easily race the database's own
+// restart.
+func NewPostgresTransactionLogger(params PostgresDBParams) (TransactionLogger, error) {
+	db, err := sql.Open("postgres", params.DSN)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open db: %w", err)
+	}
+
+	if err := withRetry(startupRetryAttempts, db.Ping); err != nil {
+		return nil, fmt.Errorf("failed to open db connection: %w", err)
+	}
+
+	l := &PostgresTransactionLogger{db: db}
+
+	if err := withRetry(startupRetryAttempts, l.verifyTableExists); err != nil {
+		return nil, fmt.Errorf("failed to verify table exists: %w", err)
+	}
+
+	return l, nil
+}
+
+func (l *PostgresTransactionLogger) verifyTableExists() error {
+	const createTable = `
+		CREATE TABLE IF NOT EXISTS transactions (
+			sequence   bigserial PRIMARY KEY,
+			event_type smallint NOT NULL,
+			key        text NOT NULL,
+			value      bytea,
+			ts         timestamptz NOT NULL DEFAULT now()
+		)`
+
+	_, err := l.db.Exec(createTable)
+	return err
+}
+
+// Run starts the asynchronous write loop. Each event is inserted with a
+// small number of retries and exponential backoff, so a transient blip in
+// connectivity to the database doesn't take down the write path the way it
+// would for FileTransactionLogger's single os.File write.
+func (l *PostgresTransactionLogger) Run() {
+	events := make(chan Event, 16)
+	l.events = events
+
+	errors := make(chan error, 1)
+	l.errors = errors
+
+	go func() {
+		for e := range events {
+			err := l.insertEventWithRetry(e)
+			if err != nil {
+				errors <- err
+				return
+			}
+		}
+	}()
+}
+
+func (l *PostgresTransactionLogger) insertEventWithRetry(e Event) error {
+	const maxAttempts = 5
+	const insertEvent = `
+		INSERT INTO transactions (event_type, key, value)
+		VALUES ($1, $2, $3)`
+
+	err := withRetry(maxAttempts, func() error {
+		_, err := l.db.Exec(insertEvent, e.EventType, e.Key, []byte(e.Value))
+		return err
+	})
+	if err != nil {
+		return fmt.Errorf("failed to write event after %d attempts: %w", maxAttempts, err)
+	}
+	return nil
+}
+
+// ReadEvents streams every row back in sequence order, exactly as
+// FileTransactionLogger.ReadEvents streams lines back in file order.
+func (l *PostgresTransactionLogger) ReadEvents() (<-chan Event, <-chan error) {
+	outEvent := make(chan Event)
+	outError := make(chan error, 1)
+
+	go func() {
+		defer close(outEvent)
+		defer close(outError)
+
+		const query = `
+			SELECT sequence, event_type, key, value
+			FROM transactions
+			ORDER BY sequence`
+
+		var rows *sql.Rows
+		err := withRetry(startupRetryAttempts, func() error {
+			var queryErr error
+			rows, queryErr = l.db.Query(query)
+			return queryErr
+		})
+		if err != nil {
+			outError <- fmt.Errorf("sql query error: %w", err)
+			return
+		}
+		defer rows.Close()
+
+		var e Event
+		var value []byte
+
+		for rows.Next() {
+			if err := rows.Scan(&e.Sequence, &e.EventType, &e.Key, &value); err != nil {
+				outError <- fmt.Errorf("error reading row: %w", err)
+				return
+			}
+			e.Value = string(value)
+
+			if l.lastSequence >= e.Sequence {
+				outError <- fmt.Errorf("transaction numbers out of sequence")
+				return
+			}
+
+			l.lastSequence = e.Sequence
+			outEvent <- e
+		}
+
+		if err := rows.Err(); err != nil {
+			outError <- fmt.Errorf("transaction log read failure: %w", err)
+			return
+		}
+	}()
+
+	return outEvent, outError
+}
@@ -0,0 +1,134 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"net"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/hashicorp/raft"
+	raftboltdb "github.com/hashicorp/raft-boltdb"
+)
+
+// Replicator proposes KV mutations through Raft consensus before they are
+// applied to myMap and the local transaction log, so that Put/Delete are
+// linearized across every node in the cluster instead of being durable on
+// only one.
+type Replicator struct {
+	raft *raft.Raft
+	fsm  *fsm
+
+	// httpAddrByRaftAddr maps each node's Raft bind address to the HTTP
+	// address clients should use to reach it. There's no way to derive one
+	// from the other, so it's supplied explicitly by configuration rather
+	// than guessed from a port offset.
+	httpAddrByRaftAddr map[string]string
+}
+
+// ReplicatorConfig describes how a node joins or bootstraps a Raft cluster.
+type ReplicatorConfig struct {
+	NodeID    string
+	RaftDir   string
+	RaftBind  string // host:port other nodes dial for Raft traffic
+	Bootstrap bool   // true only for the node that forms a brand-new cluster
+
+	// Peers maps every node's RaftBind address to its HTTP address,
+	// including this node's own. It's how LeaderHTTPAddr knows where to
+	// send clients once it learns who the Raft leader is.
+	Peers map[string]string
+}
+
+// NewReplicator starts the local Raft node. Callers are responsible for
+// joining it to an existing cluster (via the Raft API) unless Bootstrap is
+// set.
+func NewReplicator(cfg ReplicatorConfig) (*Replicator, error) {
+	if err := os.MkdirAll(cfg.RaftDir, 0755); err != nil {
+		return nil, fmt.Errorf("cannot create raft directory: %w", err)
+	}
+
+	config := raft.DefaultConfig()
+	config.LocalID = raft.ServerID(cfg.NodeID)
+
+	addr, err := net.ResolveTCPAddr("tcp", cfg.RaftBind)
+	if err != nil {
+		return nil, fmt.Errorf("cannot resolve raft bind address: %w", err)
+	}
+	transport, err := raft.NewTCPTransport(cfg.RaftBind, addr, 3, 10*time.Second, os.Stderr)
+	if err != nil {
+		return nil, fmt.Errorf("cannot create raft transport: %w", err)
+	}
+
+	snapshots, err := raft.NewFileSnapshotStore(cfg.RaftDir, 2, os.Stderr)
+	if err != nil {
+		return nil, fmt.Errorf("cannot create raft snapshot store: %w", err)
+	}
+
+	store, err := raftboltdb.NewBoltStore(filepath.Join(cfg.RaftDir, "raft.db"))
+	if err != nil {
+		return nil, fmt.Errorf("cannot create raft log/stable store: %w", err)
+	}
+
+	stateMachine := &fsm{}
+	r, err := raft.NewRaft(config, stateMachine, store, store, snapshots, transport)
+	if err != nil {
+		return nil, fmt.Errorf("cannot create raft node: %w", err)
+	}
+
+	if cfg.Bootstrap {
+		r.BootstrapCluster(raft.Configuration{
+			Servers: []raft.Server{
+				{ID: config.LocalID, Address: transport.LocalAddr()},
+			},
+		})
+	}
+
+	return &Replicator{raft: r, fsm: stateMachine, httpAddrByRaftAddr: cfg.Peers}, nil
+}
+
+// Propose submits an event to the Raft log and blocks until this node's FSM
+// has applied it.
+func (rp *Replicator) Propose(e Event) error {
+	data, err := json.Marshal(e)
+	if err != nil {
+		return fmt.Errorf("cannot marshal event: %w", err)
+	}
+
+	future := rp.raft.Apply(data, 5*time.Second)
+	if err := future.Error(); err != nil {
+		return fmt.Errorf("raft apply failed: %w", err)
+	}
+	if err, ok := future.Response().(error); ok && err != nil {
+		return fmt.Errorf("fsm apply failed: %w", err)
+	}
+	return nil
+}
+
+// Barrier blocks until every write proposed so far has been applied to this
+// node's FSM, giving a caller a linearizable read.
+func (rp *Replicator) Barrier(timeout time.Duration) error {
+	return rp.raft.Barrier(timeout).Error()
+}
+
+// IsLeader reports whether this node is the current Raft leader.
+func (rp *Replicator) IsLeader() bool {
+	return rp.raft.State() == raft.Leader
+}
+
+// LeaderHTTPAddr returns the address HTTP clients should use to reach the
+// current leader, looked up from the Peers map supplied at construction
+// time.
+func (rp *Replicator) LeaderHTTPAddr() (string, error) {
+	leaderAddr := rp.raft.Leader()
+	if leaderAddr == "" {
+		return "", fmt.Errorf("no raft leader known")
+	}
+
+	httpAddr, ok := rp.httpAddrByRaftAddr[string(leaderAddr)]
+	if !ok {
+		return "", fmt.Errorf("no known HTTP address for raft leader %q; check the configured peer list", leaderAddr)
+	}
+
+	return httpAddr, nil
+}
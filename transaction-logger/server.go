@@ -0,0 +1,162 @@
+package main
+
+import (
+	"errors"
+	"io"
+	"net/http"
+	"time"
+
+	"github.com/gorilla/mux"
+	"github.com/sirupsen/logrus"
+)
+
+// Server bundles the HTTP router with the structured logger, so each
+// request handler can enrich a base logger with per-request fields
+// (correlation ID, key, status) instead of reaching for fmt.Println.
+type Server struct {
+	router *mux.Router
+	log    *logrus.Logger
+}
+
+// NewServer builds a Server with routes registered and the shared
+// structured logger wired into its middleware.
+func NewServer() *Server {
+	s := &Server{
+		router: mux.NewRouter(),
+		log:    appLog,
+	}
+
+	s.router.Use(s.correlationIDMiddleware)
+	s.router.HandleFunc("/v1/{key}", s.keyValueGetHandler).Methods("GET")
+	s.router.HandleFunc("/v1/{key}", s.keyValuePutHandler).Methods("PUT")
+	s.router.HandleFunc("/v1/{key}", s.keyValueDeleteHandler).Methods("DELETE")
+
+	s.router.Handle("/metrics", metricsHandler).Methods("GET")
+	s.router.HandleFunc("/health", healthHandler).Methods("GET")
+	s.router.HandleFunc("/ready", readyHandler).Methods("GET")
+
+	return s
+}
+
+// ListenAndServe starts the HTTP server, blocking until it exits.
+func (s *Server) ListenAndServe(addr string) error {
+	return http.ListenAndServe(addr, s.router)
+}
+
+// redirectToLeader 307s the client at the current Raft leader, preserving
+// method and body, so writes sent to a follower still land on the node that
+// can actually commit them.
+func redirectToLeader(w http.ResponseWriter, r *http.Request) bool {
+	if replicator == nil || replicator.IsLeader() {
+		return false
+	}
+
+	leaderAddr, err := replicator.LeaderHTTPAddr()
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusServiceUnavailable)
+		return true
+	}
+
+	url := *r.URL
+	url.Scheme = "http"
+	url.Host = leaderAddr
+	http.Redirect(w, r, url.String(), http.StatusTemporaryRedirect)
+	return true
+}
+
+func (s *Server) keyValueGetHandler(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+	key := vars["key"]
+	log := loggerFromContext(r.Context()).WithField("key", key)
+
+	if replicator != nil && r.URL.Query().Get("consistency") == "linearizable" {
+		// raft.Barrier only succeeds on the leader, so a linearizable read
+		// has to redirect there exactly like a write does rather than
+		// erroring out on a follower.
+		if redirectToLeader(w, r) {
+			return
+		}
+		if err := replicator.Barrier(5 * time.Second); err != nil {
+			log.WithError(err).Error("linearizable read barrier failed")
+			http.Error(w, err.Error(), http.StatusServiceUnavailable)
+			return
+		}
+	}
+
+	value, err := Get(key)
+	if logger != nil {
+		// Nil in replicated mode, where Raft's own log and snapshot store
+		// is the durability mechanism instead (see fsm.go).
+		logger.WriteGet(key, string(value))
+	}
+
+	if errors.Is(err, ErrorNoSuchKey) {
+		http.Error(w, err.Error(), http.StatusNotFound)
+		return
+	}
+
+	w.Write([]byte(value))
+}
+
+func (s *Server) keyValuePutHandler(w http.ResponseWriter, r *http.Request) {
+	if redirectToLeader(w, r) {
+		return
+	}
+
+	vars := mux.Vars(r)
+	key := vars["key"]
+	log := loggerFromContext(r.Context()).WithField("key", key)
+
+	value, err := io.ReadAll(r.Body)
+	defer r.Body.Close()
+	if err != nil {
+		log.WithError(err).Error("failed to read request body")
+		http.Error(w,
+			err.Error(),
+			http.StatusInternalServerError)
+		return
+	}
+
+	if replicator != nil {
+		err = replicator.Propose(Event{EventType: EventPut, Key: key, Value: string(value)})
+	} else {
+		err = Put(key, string(value))
+		logger.WritePut(key, string(value))
+	}
+
+	if err != nil {
+		log.WithError(err).Error("put failed")
+		http.Error(w,
+			err.Error(),
+			http.StatusInternalServerError)
+		return
+	}
+
+	w.WriteHeader(http.StatusCreated)
+
+}
+
+func (s *Server) keyValueDeleteHandler(w http.ResponseWriter, r *http.Request) {
+	if redirectToLeader(w, r) {
+		return
+	}
+
+	vars := mux.Vars(r)
+	key := vars["key"]
+	log := loggerFromContext(r.Context()).WithField("key", key)
+
+	var err error
+	if replicator != nil {
+		err = replicator.Propose(Event{EventType: EventDelete, Key: key})
+	} else {
+		err = Delete(key)
+		logger.WriteDelete(key)
+	}
+
+	if err != nil {
+		log.WithError(err).Error("delete failed")
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	w.WriteHeader((http.StatusResetContent))
+}
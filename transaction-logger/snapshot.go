@@ -0,0 +1,119 @@
+package main
+
+import (
+	"encoding/gob"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+)
+
+// defaultSnapshotInterval is how many events FileTransactionLogger buffers
+// before it freezes the current state into a snapshot file and rotates the
+// transaction log. Left unbounded, transaction.log grows forever and a cold
+// start has to replay the entire history instead of just the tail since the
+// last snapshot.
+const defaultSnapshotInterval = 1000
+
+func snapshotPath(dir string, seq uint64) string {
+	return filepath.Join(dir, fmt.Sprintf("snapshot-%d.dat", seq))
+}
+
+// takeSnapshot freezes myMap under RLock, writes it to a new snapshot file
+// named after the sequence number it was taken at, and truncates the
+// transaction log so that the next restart only has to replay events after
+// seq.
+func (l *FileTransactionLogger) takeSnapshot(seq uint64) error {
+	myMap.RLock()
+	snapshot := make(map[string]string, len(myMap.m))
+	for k, v := range myMap.m {
+		snapshot[k] = v
+	}
+	myMap.RUnlock()
+
+	dir := filepath.Dir(l.filename)
+	tmp, err := os.CreateTemp(dir, "snapshot-*.tmp")
+	if err != nil {
+		return fmt.Errorf("cannot create snapshot temp file: %w", err)
+	}
+
+	if err := gob.NewEncoder(tmp).Encode(snapshot); err != nil {
+		tmp.Close()
+		os.Remove(tmp.Name())
+		return fmt.Errorf("cannot encode snapshot: %w", err)
+	}
+	if err := tmp.Close(); err != nil {
+		return fmt.Errorf("cannot close snapshot temp file: %w", err)
+	}
+
+	if err := os.Rename(tmp.Name(), snapshotPath(dir, seq)); err != nil {
+		return fmt.Errorf("cannot install snapshot file: %w", err)
+	}
+
+	if err := l.rotateLog(); err != nil {
+		return fmt.Errorf("cannot rotate transaction log: %w", err)
+	}
+
+	l.eventsSinceSnapshot = 0
+	return nil
+}
+
+// rotateLog truncates the transaction log now that its contents are fully
+// represented by the most recent snapshot.
+func (l *FileTransactionLogger) rotateLog() error {
+	if err := l.file.Truncate(0); err != nil {
+		return err
+	}
+	_, err := l.file.Seek(0, 0)
+	return err
+}
+
+// loadLatestSnapshot looks in dir for the newest snapshot-<seq>.dat file and
+// returns its sequence number and contents. If no snapshot exists it returns
+// a zero sequence and a nil map, and the caller should replay the
+// transaction log from the beginning.
+func loadLatestSnapshot(dir string) (uint64, map[string]string, error) {
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return 0, nil, nil
+		}
+		return 0, nil, fmt.Errorf("cannot list snapshot directory: %w", err)
+	}
+
+	var best uint64
+	var bestName string
+	for _, entry := range entries {
+		name := entry.Name()
+		if !strings.HasPrefix(name, "snapshot-") || !strings.HasSuffix(name, ".dat") {
+			continue
+		}
+		seqStr := strings.TrimSuffix(strings.TrimPrefix(name, "snapshot-"), ".dat")
+		seq, err := strconv.ParseUint(seqStr, 10, 64)
+		if err != nil {
+			continue
+		}
+		if bestName == "" || seq > best {
+			best = seq
+			bestName = name
+		}
+	}
+
+	if bestName == "" {
+		return 0, nil, nil
+	}
+
+	file, err := os.Open(filepath.Join(dir, bestName))
+	if err != nil {
+		return 0, nil, fmt.Errorf("cannot open snapshot file: %w", err)
+	}
+	defer file.Close()
+
+	var m map[string]string
+	if err := gob.NewDecoder(file).Decode(&m); err != nil {
+		return 0, nil, fmt.Errorf("cannot decode snapshot file: %w", err)
+	}
+
+	return best, m, nil
+}